@@ -0,0 +1,68 @@
+/**
+ * sticky_sessions.go - admin REST endpoint exposing a
+ * StickyPriorityBalancer's sticky table, for operators diagnosing
+ * session leaks or an unexpectedly large table.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yyyar/gobetween/balance"
+)
+
+/* default number of oldest sessions returned when ?n= is absent or invalid */
+const defaultStickySessionsLimit = 20
+
+/**
+ * stickySessionsDiagnostic is satisfied by balancers that can report
+ * sticky table diagnostics - currently only *balance.StickyPriorityBalancer.
+ */
+type stickySessionsDiagnostic interface {
+	Stats() (tableSize int, lastSweepDuration time.Duration)
+	DumpOldestSessions(n int) []balance.SessionDiagnostic
+}
+
+type stickySessionsResponse struct {
+	TableSize         int                         `json:"table_size"`
+	LastSweepDuration string                      `json:"last_sweep_duration"`
+	OldestSessions    []balance.SessionDiagnostic `json:"oldest_sessions"`
+}
+
+/**
+ * StickySessionsHandler serves a point-in-time snapshot of serverName's
+ * sticky table, eg. wired up as GET /servers/{name}/balancer/sessions?n=20.
+ * Answers 404 if registry holds no balancer for serverName, or if it
+ * holds one that isn't a stickySessionsDiagnostic (ie. anything other
+ * than a StickyPriorityBalancer).
+ */
+func StickySessionsHandler(serverName string, registry *balance.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		diagnosable, ok := registry.Get(serverName).(stickySessionsDiagnostic)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		limit := defaultStickySessionsLimit
+		if raw := r.URL.Query().Get("n"); raw != "" {
+			if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+				limit = n
+			}
+		}
+
+		tableSize, lastSweepDuration := diagnosable.Stats()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stickySessionsResponse{
+			TableSize:         tableSize,
+			LastSweepDuration: lastSweepDuration.String(),
+			OldestSessions:    diagnosable.DumpOldestSessions(limit),
+		})
+	}
+}
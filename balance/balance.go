@@ -0,0 +1,33 @@
+/**
+ * balance.go - common contract implemented by every balancing strategy.
+ */
+
+package balance
+
+import (
+	"github.com/yyyar/gobetween/core"
+)
+
+/**
+ * Balancer elects a backend for a connection out of a pool.
+ *
+ * Implementations that own background resources (timers, goroutines,
+ * persistence handles) also implement Stop, and the scheduler must call
+ * it once it discards a balancer instance - eg. on every config reload,
+ * where a fresh Balancer is constructed to replace the old one. Without
+ * this, StickyPriorityBalancer's housekeeping sweeper (and any backing
+ * SessionStore) would leak one goroutine/handle per reload.
+ */
+type Balancer interface {
+	Elect(context core.Context, backends []*core.Backend) (*core.Backend, error)
+}
+
+/**
+ * Stoppable is implemented by balancers that need to release background
+ * resources when they're discarded. The scheduler should type-assert for
+ * it after swapping in a replacement Balancer and call Stop on the old
+ * one.
+ */
+type Stoppable interface {
+	Stop()
+}
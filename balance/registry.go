@@ -0,0 +1,94 @@
+/**
+ * registry.go - holds the live Balancer for each configured server, and
+ * is the actual call site for Stoppable.Stop on config reload.
+ */
+
+package balance
+
+import "sync"
+
+/**
+ * Registry tracks the currently active Balancer per server name. The
+ * scheduler calls Replace whenever a server's config is (re)loaded; this
+ * is what makes Stoppable.Stop get called in practice, rather than just
+ * being a contract nothing invokes.
+ */
+type Registry struct {
+	mu        sync.Mutex
+	balancers map[string]Balancer
+}
+
+/**
+ * NewRegistry returns an empty Registry.
+ */
+func NewRegistry() *Registry {
+	return &Registry{
+		balancers: map[string]Balancer{},
+	}
+}
+
+/**
+ * Replace installs balancer as the active Balancer for name, stopping
+ * whatever Balancer it replaces if that one implements Stoppable. Safe
+ * for concurrent use.
+ */
+func (r *Registry) Replace(name string, balancer Balancer) {
+	r.mu.Lock()
+	old, had := r.balancers[name]
+	r.balancers[name] = balancer
+	r.mu.Unlock()
+
+	if had {
+		if stoppable, ok := old.(Stoppable); ok {
+			stoppable.Stop()
+		}
+	}
+}
+
+/**
+ * Get returns the currently active Balancer for name, or nil if none is
+ * registered.
+ */
+func (r *Registry) Get(name string) Balancer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.balancers[name]
+}
+
+/**
+ * Remove discards the Balancer registered for name, stopping it first if
+ * it implements Stoppable. Used when a server is removed from config
+ * entirely, not just reloaded.
+ */
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	old, had := r.balancers[name]
+	delete(r.balancers, name)
+	r.mu.Unlock()
+
+	if had {
+		if stoppable, ok := old.(Stoppable); ok {
+			stoppable.Stop()
+		}
+	}
+}
+
+/**
+ * StopAll stops every registered Balancer that implements Stoppable, eg.
+ * on full process shutdown.
+ */
+func (r *Registry) StopAll() {
+	r.mu.Lock()
+	balancers := make([]Balancer, 0, len(r.balancers))
+	for _, balancer := range r.balancers {
+		balancers = append(balancers, balancer)
+	}
+	r.balancers = map[string]Balancer{}
+	r.mu.Unlock()
+
+	for _, balancer := range balancers {
+		if stoppable, ok := balancer.(Stoppable); ok {
+			stoppable.Stop()
+		}
+	}
+}
@@ -0,0 +1,33 @@
+/**
+ * sessionstore.go - pluggable persistence for StickyPriorityBalancer's
+ * sticky table, so sessions can survive a restart or be shared across an
+ * HA pair of gobetween instances.
+ */
+
+package balance
+
+import (
+	"errors"
+	"time"
+)
+
+/* returned by SessionStore.Load when key has no persisted entry */
+var errSessionNotFound = errors.New("balance: session not found in store")
+
+/**
+ * SessionStore persists sticky session state. Implementations must be
+ * safe for concurrent use. A failing store must never block or fail an
+ * Elect() call - StickyPriorityBalancer only logs store errors.
+ */
+type SessionStore interface {
+	Load(key string) (backendAddr string, lasttouch time.Time, err error)
+	Save(key string, backendAddr string, lasttouch time.Time) error
+	Delete(key string) error
+	Range(fn func(key, backendAddr string, lasttouch time.Time)) error
+
+	/* Close releases any underlying handle (file lock, connection pool).
+	   StickyPriorityBalancer.Stop() calls this, so a store backed by a
+	   file lock (eg. the bolt store) doesn't wedge the next config
+	   reload waiting on a lock nothing will ever release. */
+	Close() error
+}
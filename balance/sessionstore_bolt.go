@@ -0,0 +1,96 @@
+/**
+ * sessionstore_bolt.go - local file-backed SessionStore implementation,
+ * used when the sticky session store type is "file".
+ */
+
+package balance
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var boltSessionBucket = []byte("sticky_priority_sessions")
+
+type boltSessionRecord struct {
+	BackendAddr string    `json:"backend_addr"`
+	LastTouch   time.Time `json:"last_touch"`
+}
+
+type boltSessionStore struct {
+	db *bolt.DB
+}
+
+/**
+ * NewBoltSessionStore opens (creating if necessary) a BoltDB file at path
+ * to use as the sticky session store.
+ */
+func NewBoltSessionStore(path string) (SessionStore, error) {
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltSessionBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &boltSessionStore{db: db}, nil
+}
+
+func (s *boltSessionStore) Load(key string) (string, time.Time, error) {
+
+	var rec boltSessionRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(boltSessionBucket).Get([]byte(key))
+		if raw == nil {
+			return errSessionNotFound
+		}
+		return json.Unmarshal(raw, &rec)
+	})
+
+	return rec.BackendAddr, rec.LastTouch, err
+}
+
+func (s *boltSessionStore) Save(key string, backendAddr string, lasttouch time.Time) error {
+
+	raw, err := json.Marshal(boltSessionRecord{BackendAddr: backendAddr, LastTouch: lasttouch})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSessionBucket).Put([]byte(key), raw)
+	})
+}
+
+func (s *boltSessionStore) Delete(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSessionBucket).Delete([]byte(key))
+	})
+}
+
+func (s *boltSessionStore) Range(fn func(key, backendAddr string, lasttouch time.Time)) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSessionBucket).ForEach(func(k, raw []byte) error {
+			var rec boltSessionRecord
+			if err := json.Unmarshal(raw, &rec); err != nil {
+				return err
+			}
+			fn(string(k), rec.BackendAddr, rec.LastTouch)
+			return nil
+		})
+	})
+}
+
+func (s *boltSessionStore) Close() error {
+	return s.db.Close()
+}
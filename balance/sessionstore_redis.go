@@ -0,0 +1,100 @@
+/**
+ * sessionstore_redis.go - Redis-backed SessionStore implementation, for
+ * sharing sticky session state across an HA pair of gobetween instances.
+ */
+
+package balance
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type redisSessionStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+/**
+ * NewRedisSessionStore connects to a Redis server to use as the sticky
+ * session store. Keys are namespaced under prefix so multiple balancers
+ * can share the same Redis instance.
+ *
+ * ttl, if positive, is set as an EXPIRE on every Save, independent of
+ * this process's own sweeper. It's a backstop, not the primary expiry
+ * mechanism: a process that dies without sweeping (crash, kill -9) would
+ * otherwise leave its sessions in Redis forever.
+ */
+func NewRedisSessionStore(addr string, password string, db int, prefix string, ttl time.Duration) SessionStore {
+	return &redisSessionStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+func (s *redisSessionStore) redisKey(key string) string {
+	return s.prefix + key
+}
+
+func (s *redisSessionStore) Load(key string) (string, time.Time, error) {
+
+	vals, err := s.client.HMGet(s.redisKey(key), "backend", "lasttouch").Result()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if vals[0] == nil {
+		return "", time.Time{}, errSessionNotFound
+	}
+
+	backendAddr, _ := vals[0].(string)
+	rawLastTouch, _ := vals[1].(string)
+	unixNano, _ := strconv.ParseInt(rawLastTouch, 10, 64)
+
+	return backendAddr, time.Unix(0, unixNano), nil
+}
+
+func (s *redisSessionStore) Save(key string, backendAddr string, lasttouch time.Time) error {
+	redisKey := s.redisKey(key)
+
+	if err := s.client.HMSet(redisKey, map[string]interface{}{
+		"backend":   backendAddr,
+		"lasttouch": strconv.FormatInt(lasttouch.UnixNano(), 10),
+	}).Err(); err != nil {
+		return err
+	}
+
+	if s.ttl <= 0 {
+		return nil
+	}
+	return s.client.Expire(redisKey, s.ttl).Err()
+}
+
+func (s *redisSessionStore) Delete(key string) error {
+	return s.client.Del(s.redisKey(key)).Err()
+}
+
+func (s *redisSessionStore) Range(fn func(key, backendAddr string, lasttouch time.Time)) error {
+	iter := s.client.Scan(0, s.prefix+"*", 100).Iterator()
+	for iter.Next() {
+		key := strings.TrimPrefix(iter.Val(), s.prefix)
+		backendAddr, lasttouch, err := s.Load(key)
+		if err != nil {
+			continue
+		}
+		fn(key, backendAddr, lasttouch)
+	}
+	return iter.Err()
+}
+
+func (s *redisSessionStore) Close() error {
+	return s.client.Close()
+}
@@ -9,7 +9,15 @@
 package balance
 
 import (
+	"container/list"
+	gocontext "context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
 	"errors"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/yyyar/gobetween/config"
@@ -17,15 +25,97 @@ import (
 	"github.com/yyyar/gobetween/logging"
 )
 
+/**
+ * StickyAffinityMode controls what key StickyPriorityBalancer uses to
+ * look up a client's session in its sticky table.
+ */
+type StickyAffinityMode string
+
+const (
+	StickyAffinityIp       StickyAffinityMode = "ip"
+	StickyAffinityCookie   StickyAffinityMode = "cookie"
+	StickyAffinityIpCookie StickyAffinityMode = "ip+cookie"
+)
+
+/* sweeps at least this often, however short the idle expiry is configured */
+const maxSweepInterval = time.Second
+
+/* size of the buffer used to capture the creating goroutine's stacktrace
+   when leak diagnostics are enabled */
+const leakDiagnosticStackSize = 8192
+
+/**
+ * cookieContext is implemented by core.Context's that can carry an HTTP
+ * cookie (ie. the L7 HTTP/HTTPS proxy). TCP/UDP contexts don't implement
+ * it, so the balancer falls back to ip based affinity for them.
+ */
+type cookieContext interface {
+	Cookie(name string) (string, bool)
+	SetCookie(name, value, domain string, ttl time.Duration, secure, httpOnly bool)
+}
+
+/**
+ * cancellableContext is implemented by core.Context's that can report
+ * when the underlying connection has gone away (ie. the L7 HTTP/HTTPS
+ * proxy, whose request carries its own context.Context). TCP/UDP
+ * contexts don't implement it, so electOrFallback's retry loop only ever
+ * bounds itself on b.retryDeadline for them.
+ */
+type cancellableContext interface {
+	Done() <-chan struct{}
+}
+
 /**
  * balancer implements "sticky" priority based balancing.
  */
 type StickyPriorityBalancer struct {
 	duration time.Duration
 
-	/* sticky table mapping */
-	/* ip str -> session */
+	/* session affinity mode: ip, cookie or ip+cookie */
+	affinity StickyAffinityMode
+
+	/* cookie settings, only used when affinity is cookie or ip+cookie */
+	cookieName     string
+	cookieDomain   string
+	cookieSecure   bool
+	cookieHttpOnly bool
+
+	/* sticky table mapping, guarded by mu since Elect and the sweeper
+	   goroutine both touch it concurrently */
+	mu sync.RWMutex
+	/* session key (ip str, or cookie value) -> session */
 	table map[string]*StickyPrioritySession
+	/* intrusive LRU ordering over table, most-recently-touched at Front() */
+	lru *list.List
+
+	/* 0 means unbounded. Populated from config.BalanceConfig's
+	   StickyPrioritySessionMaxSessions field. */
+	maxSessions int
+
+	/* when true, Elect captures the creating goroutine's stacktrace so
+	   DumpOldestSessions can help find a caller leaking sticky state */
+	leakDiagnostics bool
+
+	/* housekeeping sweeper */
+	sweepInterval     time.Duration
+	lastSweepDuration time.Duration
+	quit              chan struct{}
+	stopOnce          sync.Once
+
+	/* backoff applied to the inner PriorityBalancer.Elect call when it
+	   reports no healthy backend, eg. during a health-check flap */
+	retryBase        time.Duration
+	retryCap         time.Duration
+	retryMaxAttempts int
+	retryDeadline    time.Duration
+
+	/* last resort backend used once retries are exhausted, even if it's
+	   a stale/unhealthy sticky target or a dedicated sink */
+	lastResort *core.Backend
+
+	/* optional - nil means in-memory only, which remains the zero-config
+	   default */
+	store SessionStore
 }
 
 /**
@@ -33,8 +123,26 @@ type StickyPriorityBalancer struct {
  */
 type StickyPrioritySession struct {
 	backend   *core.Backend
-	timer     *time.Timer
 	lasttouch time.Time
+
+	/* position of this session's key in b.lru */
+	element *list.Element
+
+	/* only populated when leak diagnostics are enabled */
+	createdAt time.Time
+	stack     []byte
+}
+
+/**
+ * SessionDiagnostic is a point-in-time snapshot of a sticky session,
+ * returned by DumpOldestSessions for the admin REST server to expose.
+ */
+type SessionDiagnostic struct {
+	Key       string
+	Backend   string
+	CreatedAt time.Time
+	LastTouch time.Time
+	Stack     string
 }
 
 /**
@@ -42,100 +150,588 @@ type StickyPrioritySession struct {
  */
 func NewStickyPriorityBalancer(cfg config.BalanceConfig) interface{} {
 
+	affinity := StickyAffinityMode(cfg.StickyPrioritySessionAffinity)
+	if affinity == "" {
+		affinity = StickyAffinityIp
+	}
+
+	cookieName := cfg.StickyPrioritySessionCookieName
+	if cookieName == "" {
+		cookieName = "gobetween"
+	}
+
 	b := &StickyPriorityBalancer{
-		table: map[string]*StickyPrioritySession{},
+		table:           map[string]*StickyPrioritySession{},
+		lru:             list.New(),
+		affinity:        affinity,
+		cookieName:      cookieName,
+		cookieDomain:    cfg.StickyPrioritySessionCookieDomain,
+		cookieSecure:    cfg.StickyPrioritySessionCookieSecure,
+		cookieHttpOnly:  cfg.StickyPrioritySessionCookieHttpOnly,
+		maxSessions:     cfg.StickyPrioritySessionMaxSessions,
+		leakDiagnostics: cfg.StickyPrioritySessionLeakDiagnostics,
+		quit:            make(chan struct{}),
 	}
 
 	b.duration, _ = time.ParseDuration(cfg.StickyPrioritySessionIdleExpiry)
 
+	b.sweepInterval = b.duration / 10
+	if b.sweepInterval <= 0 || b.sweepInterval > maxSweepInterval {
+		b.sweepInterval = maxSweepInterval
+	}
+
+	b.retryBase, _ = time.ParseDuration(cfg.StickyPrioritySessionElectRetryBase)
+	if b.retryBase <= 0 {
+		b.retryBase = 50 * time.Millisecond
+	}
+
+	b.retryCap, _ = time.ParseDuration(cfg.StickyPrioritySessionElectRetryCap)
+	if b.retryCap <= 0 {
+		b.retryCap = time.Second
+	}
+
+	b.retryMaxAttempts = cfg.StickyPrioritySessionElectRetryMaxAttempts
+	if b.retryMaxAttempts <= 0 {
+		b.retryMaxAttempts = 3
+	}
+
+	b.retryDeadline, _ = time.ParseDuration(cfg.StickyPrioritySessionElectRetryDeadline)
+	if b.retryDeadline <= 0 {
+		b.retryDeadline = 2 * time.Second
+	}
+
+	if cfg.StickyPrioritySessionLastResortBackend != "" {
+		b.lastResort = core.NewBackend(cfg.StickyPrioritySessionLastResortBackend)
+	}
+
+	switch cfg.StickyPrioritySessionStoreType {
+	case "redis":
+		redisTtl, err := time.ParseDuration(cfg.StickyPrioritySessionStoreRedisTtl)
+		if err != nil || redisTtl <= 0 {
+			// backstop only, independent of the sweeper - default to
+			// comfortably outliving the idle expiry rather than racing it.
+			redisTtl = b.duration * 2
+		}
+		b.store = NewRedisSessionStore(
+			cfg.StickyPrioritySessionStoreRedisAddr,
+			cfg.StickyPrioritySessionStoreRedisPassword,
+			cfg.StickyPrioritySessionStoreRedisDb,
+			cfg.StickyPrioritySessionStoreRedisPrefix,
+			redisTtl,
+		)
+	case "file":
+		store, err := NewBoltSessionStore(cfg.StickyPrioritySessionStorePath)
+		if err != nil {
+			logging.For("balance/StickyPriority").Error("failed to open sticky session store at ", cfg.StickyPrioritySessionStorePath, ": ", err)
+		} else {
+			b.store = store
+		}
+	}
+
+	b.hydrate()
+
+	go b.sweep()
+
 	return b
 }
 
 /**
- * Elect backend using priority strategy
- * It keeps mapping cache for some period of time.
+ * hydrate loads any previously persisted sessions into the in-memory
+ * table on startup. Loaded entries are re-validated against the current
+ * backend pool lazily, on their first Elect() call, same as any other
+ * table entry.
+ *
+ * The store's iteration order (key order/SCAN order) has nothing to do with
+ * recency, so entries are sorted by lasttouch before they're threaded
+ * onto the LRU list - otherwise the list would claim an arbitrary entry
+ * is "oldest" right after startup. If the store holds more sessions than
+ * the configured max_sessions, only the most recently touched ones are
+ * kept: evictOldestLocked only ever drops one entry per new-client
+ * insert, so without this trim an over-capacity store would leave the
+ * table oversized indefinitely instead of converging back down to the
+ * cap.
  */
-func (b *StickyPriorityBalancer) Elect(context core.Context, backends []*core.Backend) (*core.Backend, error) {
+func (b *StickyPriorityBalancer) hydrate() {
+	if b.store == nil {
+		return
+	}
+
 	log := logging.For("balance/StickyPriority")
 
-	if len(backends) == 0 {
-		return nil, errors.New("Can't elect backend, Backends empty")
+	type storedSession struct {
+		key         string
+		backendAddr string
+		lasttouch   time.Time
+	}
+
+	var entries []storedSession
+	err := b.store.Range(func(key, backendAddr string, lasttouch time.Time) {
+		entries = append(entries, storedSession{key, backendAddr, lasttouch})
+	})
+	if err != nil {
+		log.Error("failed to hydrate sticky session table from store: ", err)
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lasttouch.After(entries[j].lasttouch)
+	})
+
+	if b.maxSessions > 0 && len(entries) > b.maxSessions {
+		log.Info("store holds ", len(entries), " sessions, trimming to max_sessions (", b.maxSessions, ") on hydrate")
+		entries = entries[:b.maxSessions]
+	}
+
+	for _, e := range entries {
+		b.table[e.key] = &StickyPrioritySession{
+			backend:   core.NewBackend(e.backendAddr),
+			lasttouch: e.lasttouch,
+			createdAt: e.lasttouch,
+			element:   b.lru.PushBack(e.key),
+		}
+	}
+
+	log.Info("hydrated ", len(b.table), " sticky sessions from store")
+}
+
+/**
+ * persistAsync writes a session through to the store off the hot path,
+ * since a failing or slow store must never block Elect().
+ */
+func (b *StickyPriorityBalancer) persistAsync(key string, sess *StickyPrioritySession) {
+	if b.store == nil {
+		return
+	}
+
+	backendAddr, lasttouch := sess.backend.Address(), sess.lasttouch
+	go func() {
+		if err := b.store.Save(key, backendAddr, lasttouch); err != nil {
+			logging.For("balance/StickyPriority").Error("failed to persist sticky session for ", key, ": ", err)
+		}
+	}()
+}
+
+/**
+ * deleteAsync removes a session from the store off the hot path.
+ */
+func (b *StickyPriorityBalancer) deleteAsync(key string) {
+	if b.store == nil {
+		return
 	}
 
+	go func() {
+		if err := b.store.Delete(key); err != nil {
+			logging.For("balance/StickyPriority").Error("failed to delete persisted sticky session for ", key, ": ", err)
+		}
+	}()
+}
+
+/**
+ * electWithBackoff retries the inner priority election under an
+ * exponential backoff with jitter, bounded by ctx's deadline, so a
+ * transient empty healthy-set (eg. health checks mid-flap) doesn't cause
+ * a hard rejection that a retry microseconds later would have avoided.
+ */
+func (b *StickyPriorityBalancer) electWithBackoff(ctx gocontext.Context, context core.Context, backends []*core.Backend) (*core.Backend, error) {
+	log := logging.For("balance/StickyPriority/backoff")
+
+	delay := b.retryBase
 	var backend *core.Backend
 	var err error
-	sess, ok := b.table[context.Ip().String()]
-	if !ok {
-		// we couldnt find an existing session;
-		// - make one + give it a valid backend
-		// - set up a timer to clean up once idle expiry time has been reached
 
+	for attempt := 0; ; attempt++ {
 		backend, err = ((*PriorityBalancer)(nil)).Elect(context, backends)
-		b.table[context.Ip().String()] = &StickyPrioritySession{
-			backend: backend,
+		if err == nil {
+			return backend, nil
 		}
 
-		sess = b.table[context.Ip().String()]
+		if attempt >= b.retryMaxAttempts {
+			return nil, err
+		}
 
-		// touch the session
-		sess.lasttouch = time.Now()
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2+1)))
+		log.Debug("elect attempt ", attempt+1, " failed (", err, "), retrying in ", wait)
 
-		// set the timer going
-		setTimer(context, *b)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 
-		log.Info("client ", context.Ip(), " new session on backend ", sess.backend.Address())
+		delay *= 2
+		if delay > b.retryCap {
+			delay = b.retryCap
+		}
+	}
+}
 
-	} else {
-		// got a session, check if previously elected backend is valid
-		for _, validbackend := range backends {
-			if validbackend.Address() == sess.backend.Address() {
-				backend = validbackend
-				// if the backend has been flagged to drain sessions, then we stop updating the
-				// 'lasttouch' for that guy and let the session expiry normally.
-				if backend.DrainSessions != true {
-					sess.lasttouch = time.Now()
+/**
+ * electOrFallback wraps electWithBackoff with a bounded deadline and,
+ * if every retry still comes back empty, falls back to the caller's
+ * stale sticky target (if any) or the configured last-resort backend.
+ *
+ * The deadline is derived from context (via cancellableContext) as well
+ * as b.retryDeadline: whichever fires first ends the retry loop, so a
+ * client that disconnects mid-retry doesn't leave its goroutine blocked
+ * for the full configured deadline regardless.
+ */
+func (b *StickyPriorityBalancer) electOrFallback(context core.Context, backends []*core.Backend, stale *core.Backend) (*core.Backend, error) {
+	log := logging.For("balance/StickyPriority")
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), b.retryDeadline)
+	defer cancel()
+
+	if cc, ok := context.(cancellableContext); ok {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-cc.Done():
+				cancel()
+			case <-done:
+			}
+		}()
+	}
+
+	backend, err := b.electWithBackoff(ctx, context, backends)
+	if err == nil {
+		return backend, nil
+	}
+
+	fallback := stale
+	if fallback == nil {
+		fallback = b.lastResort
+	}
+
+	if fallback != nil {
+		log.Info("client ", context.Ip(), " elect failed after retries (", err, "), falling back to ", fallback.Address())
+		return fallback, nil
+	}
+
+	return nil, err
+}
+
+/**
+ * Stop shuts down the balancer's housekeeping goroutine, satisfying the
+ * balance.Stoppable contract. The scheduler must call this whenever it
+ * discards a StickyPriorityBalancer instance - most importantly on every
+ * config reload, where a fresh balancer is constructed to replace this
+ * one. Safe to call more than once.
+ */
+func (b *StickyPriorityBalancer) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.quit)
+
+		if b.store != nil {
+			if err := b.store.Close(); err != nil {
+				logging.For("balance/StickyPriority").Error("failed to close sticky session store: ", err)
+			}
+		}
+	})
+}
+
+/**
+ * Stats exposes the current sticky table size and the duration the last
+ * sweep pass took. Scraped by the admin metrics endpoint alongside
+ * DumpOldestSessions - see api.StickySessionsHandler.
+ */
+func (b *StickyPriorityBalancer) Stats() (tableSize int, lastSweepDuration time.Duration) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.table), b.lastSweepDuration
+}
+
+/**
+ * removeLocked deletes key from the table and its LRU entry. Caller must
+ * hold b.mu.
+ */
+func (b *StickyPriorityBalancer) removeLocked(key string, sess *StickyPrioritySession) {
+	delete(b.table, key)
+	if sess.element != nil {
+		b.lru.Remove(sess.element)
+	}
+}
+
+/**
+ * evictOldestLocked drops the least-recently-touched session once the
+ * table is at capacity, so a churn of one-shot clients can't grow it
+ * without bound. Caller must hold b.mu.
+ */
+func (b *StickyPriorityBalancer) evictOldestLocked() {
+	if b.maxSessions <= 0 || len(b.table) < b.maxSessions {
+		return
+	}
+
+	oldest := b.lru.Back()
+	if oldest == nil {
+		return
+	}
+
+	key := oldest.Value.(string)
+	log := logging.For("balance/StickyPriority")
+	log.Info("client ", key, " evicted - sticky table at capacity (", b.maxSessions, ")")
+
+	if sess, ok := b.table[key]; ok {
+		b.removeLocked(key, sess)
+		b.deleteAsync(key)
+	}
+}
+
+/**
+ * DumpOldestSessions returns a snapshot of the n least-recently-touched
+ * sessions, including their creation stacktrace when leak diagnostics
+ * are enabled. Exposed over the admin REST API by
+ * api.StickySessionsHandler, eg. GET /servers/{name}/balancer/sessions?n=...
+ */
+func (b *StickyPriorityBalancer) DumpOldestSessions(n int) []SessionDiagnostic {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	diags := make([]SessionDiagnostic, 0, n)
+	for e := b.lru.Back(); e != nil && len(diags) < n; e = e.Prev() {
+		key := e.Value.(string)
+		sess, ok := b.table[key]
+		if !ok {
+			continue
+		}
+		diags = append(diags, SessionDiagnostic{
+			Key:       key,
+			Backend:   sess.backend.Address(),
+			CreatedAt: sess.createdAt,
+			LastTouch: sess.lasttouch,
+			Stack:     string(sess.stack),
+		})
+	}
+	return diags
+}
+
+/**
+ * sweep periodically removes table entries that have been idle for longer
+ * than the configured expiry, replacing the old per-session time.AfterFunc
+ * timers (and the goroutines they spawned on every fire).
+ */
+func (b *StickyPriorityBalancer) sweep() {
+	log := logging.For("balance/StickyPriority/sweep")
+
+	ticker := time.NewTicker(b.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			start := time.Now()
+
+			b.mu.Lock()
+			for key, sess := range b.table {
+				if now.After(sess.lasttouch.Add(b.duration)) {
+					b.removeLocked(key, sess)
+					b.deleteAsync(key)
+					log.Debug("client ", key, " sweep - session expired")
 				}
-				break
 			}
+			b.lastSweepDuration = time.Since(start)
+			b.mu.Unlock()
+
+		case <-b.quit:
+			log.Debug("sweeper stopping")
+			return
 		}
-		// couldnt find the old backend? get a new one!
-		if backend == nil {
-			backend, err = ((*PriorityBalancer)(nil)).Elect(context, backends)
-			log.Debug("client ", context.Ip(), " existing backend not valid, selected new one ", sess.backend.Address())
-			sess.backend = backend
-			sess.lasttouch = time.Now()
+	}
+}
+
+/**
+ * sessionTokenSize is the size, in random bytes, of a newly minted
+ * affinity cookie value - 16 bytes of crypto/rand is enough that guessing
+ * or colliding with another client's token is not a practical concern.
+ */
+const sessionTokenSize = 16
+
+/**
+ * newSessionToken mints a random opaque token to hand out as a fresh
+ * affinity cookie's value. It must never be derived from anything the
+ * client already controls (eg. its own ip) - two distinct clients behind
+ * the same NAT/proxy would otherwise be handed the same token on their
+ * first request and collide on the same sticky-table key forever after.
+ */
+func newSessionToken() string {
+	buf := make([]byte, sessionTokenSize)
+	if _, err := cryptorand.Read(buf); err != nil {
+		// crypto/rand failing is effectively unreachable on every platform
+		// gobetween supports; math/rand at least keeps the balancer
+		// functional (sessions just aren't unguessable) instead of panicking.
+		for i := range buf {
+			buf[i] = byte(rand.Intn(256))
+		}
+	}
+	return hex.EncodeToString(buf)
+}
+
+/**
+ * sessionKey works out what key to use for the sticky table lookup and
+ * what value the affinity cookie should carry, depending on the
+ * configured affinity mode:
+ *
+ *   - ip:        key is always the client ip.
+ *   - cookie:    key is the cookie value alone, so a client keeps its
+ *                backend across ip changes (mobile roaming, multi-WAN).
+ *   - ip+cookie: key binds *both* signals (ip + cookie value). A cookie
+ *                replayed from a different ip doesn't inherit the old
+ *                session - it's treated as a fresh one - which is the
+ *                behavior that actually distinguishes this mode from
+ *                plain cookie affinity.
+ *
+ * Cookie based modes fall back to ip alone whenever the context doesn't
+ * carry cookies (UDP/TCP). When the context does carry cookies but the
+ * client hasn't been handed one yet, a fresh opaque token is minted
+ * (newSessionToken) rather than reusing the client ip as the cookie
+ * value - otherwise every client behind the same NAT/proxy would be
+ * issued the same value on their first request and collide on the same
+ * key, identically to plain ip mode.
+ */
+func (b *StickyPriorityBalancer) sessionKey(context core.Context) (key string, cookieValue string, fromCookie bool) {
+
+	ip := context.Ip().String()
+
+	if b.affinity == StickyAffinityIp {
+		return ip, "", false
+	}
+
+	cc, supportsCookies := context.(cookieContext)
+	if !supportsCookies {
+		return ip, "", false
+	}
+
+	if value, ok := cc.Cookie(b.cookieName); ok && value != "" {
+		if b.affinity == StickyAffinityIpCookie {
+			return ip + "|" + value, value, true
 		}
+		return value, value, true
 	}
 
-	return backend, err
+	token := newSessionToken()
+	if b.affinity == StickyAffinityIpCookie {
+		return ip + "|" + token, token, false
+	}
+	return token, token, false
 }
 
-func setTimer(context core.Context, b StickyPriorityBalancer) {
-	log := logging.For("balance/StickyPriority/setTimer")
+/**
+ * touchCookie issues the affinity cookie on the response when running in
+ * cookie or ip+cookie mode and the context supports it.
+ */
+func (b *StickyPriorityBalancer) touchCookie(context core.Context, cookieValue string) {
+
+	if b.affinity == StickyAffinityIp {
+		return
+	}
 
-	log.Debug("client ", context.Ip().String(), " setting expirycheck timer")
+	if cc, ok := context.(cookieContext); ok {
+		cc.SetCookie(b.cookieName, cookieValue, b.cookieDomain, b.duration, b.cookieSecure, b.cookieHttpOnly)
+	}
+}
 
-	sess := b.table[context.Ip().String()]
-	// expiry seconds is; lasttouch + duration of expiry - timenow.
-	expirysecs := sess.lasttouch.Add(b.duration).Sub(time.Now())
+/**
+ * validBackendFor returns the entry in backends addressed the same as
+ * addr, or nil if it's no longer part of the healthy set.
+ */
+func validBackendFor(addr string, backends []*core.Backend) *core.Backend {
+	for _, backend := range backends {
+		if backend.Address() == addr {
+			return backend
+		}
+	}
+	return nil
+}
 
-	// if expirysecs < 0, then afterfunc will ignore it (accoring to sleep.go doco)
-	sess.timer = time.AfterFunc(expirysecs, func() {
-		// wait for the timer to expiry, then do this to see if we need to clean up:
-		log.Info("client ", context.Ip().String(), " expirytimer - triggered")
-		sess := b.table[context.Ip().String()]
-		if sess != nil {
-			log.Info("client ", context.Ip().String(), " expirytimer - found existing session")
-			if time.Now().After(sess.lasttouch.Add(b.duration)) {
-				log.Info("client ", context.Ip().String(), " expirytimer - session expired")
-				delete(b.table, context.Ip().String())
-				log.Info("client ", context.Ip().String(), " expirytimer - session deleted")
-			} else {
-				log.Info("client ", context.Ip().String(), " expirytimer - session not expired, setting new timer")
-				setTimer(context, b)
+/**
+ * Elect backend using priority strategy
+ * It keeps mapping cache for some period of time.
+ *
+ * Election (which may retry with backoff against the inner
+ * PriorityBalancer - see electOrFallback) always happens *outside* b.mu,
+ * so a backend flap doesn't stall every other client's Elect, the
+ * sweeper, Stats() or DumpOldestSessions() for the length of the retry
+ * window. The table is only ever touched under the lock, and briefly.
+ */
+func (b *StickyPriorityBalancer) Elect(context core.Context, backends []*core.Backend) (*core.Backend, error) {
+	log := logging.For("balance/StickyPriority")
+
+	if len(backends) == 0 {
+		return nil, errors.New("Can't elect backend, Backends empty")
+	}
+
+	key, cookieValue, fromCookie := b.sessionKey(context)
+
+	b.mu.RLock()
+	sess, found := b.table[key]
+	var stale *core.Backend
+	var valid *core.Backend
+	if found {
+		stale = sess.backend
+		valid = validBackendFor(stale.Address(), backends)
+	}
+	b.mu.RUnlock()
+
+	if found && valid != nil {
+		// existing session, previously elected backend still valid
+		if valid.DrainSessions != true {
+			b.mu.Lock()
+			current, stillPresent := b.table[key]
+			if stillPresent {
+				current.lasttouch = time.Now()
+				b.lru.MoveToFront(current.element)
+				b.persistAsync(key, current)
 			}
-		} else {
-			log.Info("client ", context.Ip().String(), " expirytimer - session not found")
+			b.mu.Unlock()
 		}
-	})
+		return valid, nil
+	}
+
+	// no existing session, or the previously elected backend is no
+	// longer valid - (re-)elect, outside the table lock.
+	backend, err := b.electOrFallback(context, backends, stale)
+	if err != nil {
+		// election and every fallback failed - report the error without
+		// ever touching the table, so we don't leave a session pointing
+		// at a nil backend for the next caller to dereference.
+		return nil, err
+	}
+
+	b.mu.Lock()
+	sess, found = b.table[key]
+	if !found {
+		b.evictOldestLocked()
+
+		now := time.Now()
+		sess = &StickyPrioritySession{
+			backend:   backend,
+			lasttouch: now,
+			createdAt: now,
+		}
+		if b.leakDiagnostics {
+			buf := make([]byte, leakDiagnosticStackSize)
+			sess.stack = buf[:runtime.Stack(buf, false)]
+		}
+		sess.element = b.lru.PushFront(key)
+		b.table[key] = sess
+	} else {
+		sess.backend = backend
+		sess.lasttouch = time.Now()
+		b.lru.MoveToFront(sess.element)
+	}
+	b.persistAsync(key, sess)
+	b.mu.Unlock()
+
+	if !found {
+		// hand the client a cookie for next time, unless it already
+		// presented one we matched on
+		if !fromCookie {
+			b.touchCookie(context, cookieValue)
+		}
+		log.Info("client ", key, " new session on backend ", backend.Address())
+	} else {
+		log.Debug("client ", key, " existing backend not valid, selected new one ", backend.Address())
+	}
+
+	return backend, nil
 }
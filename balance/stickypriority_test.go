@@ -0,0 +1,113 @@
+package balance
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yyyar/gobetween/config"
+	"github.com/yyyar/gobetween/core"
+)
+
+func testBackends(n int) []*core.Backend {
+	backends := make([]*core.Backend, n)
+	for i := 0; i < n; i++ {
+		backends[i] = &core.Backend{}
+		backends[i].Priority = 1
+	}
+	return backends
+}
+
+func TestStickyPriorityBalancerMaxSessionsBounded(t *testing.T) {
+	cfg := config.BalanceConfig{
+		StickyPrioritySessionIdleExpiry: "1h",
+		StickyPrioritySessionMaxSessions: 10,
+	}
+
+	b := NewStickyPriorityBalancer(cfg).(*StickyPriorityBalancer)
+	defer b.Stop()
+
+	backends := testBackends(3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := core.NewContext(fmt.Sprintf("10.0.0.%d", i%256))
+			if _, err := b.Elect(ctx, backends); err != nil {
+				t.Errorf("unexpected Elect error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	size, _ := b.Stats()
+	if size > cfg.StickyPrioritySessionMaxSessions {
+		t.Fatalf("table grew past max_sessions: got %d, want <= %d", size, cfg.StickyPrioritySessionMaxSessions)
+	}
+}
+
+func TestStickyPriorityBalancerLRUEviction(t *testing.T) {
+	cfg := config.BalanceConfig{
+		StickyPrioritySessionIdleExpiry: "1h",
+		StickyPrioritySessionMaxSessions: 2,
+	}
+
+	b := NewStickyPriorityBalancer(cfg).(*StickyPriorityBalancer)
+	defer b.Stop()
+
+	backends := testBackends(1)
+
+	elect := func(ip string) {
+		if _, err := b.Elect(core.NewContext(ip), backends); err != nil {
+			t.Fatalf("unexpected Elect error: %v", err)
+		}
+	}
+
+	elect("10.0.0.1")
+	elect("10.0.0.2")
+	// touching .1 again should keep it alive over .2 when .3 forces an eviction
+	elect("10.0.0.1")
+	elect("10.0.0.3")
+
+	b.mu.RLock()
+	_, hasOne := b.table["10.0.0.1"]
+	_, hasTwo := b.table["10.0.0.2"]
+	_, hasThree := b.table["10.0.0.3"]
+	b.mu.RUnlock()
+
+	if hasTwo {
+		t.Error("expected least-recently-touched session 10.0.0.2 to be evicted")
+	}
+	if !hasOne || !hasThree {
+		t.Error("expected recently touched sessions to remain in table")
+	}
+}
+
+func TestStickyPriorityBalancerDumpOldestSessions(t *testing.T) {
+	cfg := config.BalanceConfig{
+		StickyPrioritySessionIdleExpiry:     "1h",
+		StickyPrioritySessionLeakDiagnostics: true,
+	}
+
+	b := NewStickyPriorityBalancer(cfg).(*StickyPriorityBalancer)
+	defer b.Stop()
+
+	backends := testBackends(1)
+	if _, err := b.Elect(core.NewContext("10.0.0.1"), backends); err != nil {
+		t.Fatalf("unexpected Elect error: %v", err)
+	}
+
+	diags := b.DumpOldestSessions(10)
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(diags))
+	}
+	if diags[0].Stack == "" {
+		t.Error("expected leak diagnostics to capture a stacktrace")
+	}
+	if time.Since(diags[0].CreatedAt) > time.Second {
+		t.Error("expected CreatedAt to be set to roughly now")
+	}
+}
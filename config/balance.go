@@ -0,0 +1,42 @@
+/**
+ * balance.go - configuration for a backend pool's balancing strategy.
+ */
+
+package config
+
+/**
+ * BalanceConfig configures how a single backend pool elects a backend
+ * per connection.
+ */
+type BalanceConfig struct {
+	Balance string `json:"balance" toml:"balance"`
+
+	StickyPrioritySessionIdleExpiry string `json:"sticky_priority_session_idle_expiry" toml:"sticky_priority_session_idle_expiry"`
+
+	/* session affinity: "ip" (default), "cookie" or "ip+cookie" */
+	StickyPrioritySessionAffinity string `json:"sticky_priority_session_affinity" toml:"sticky_priority_session_affinity"`
+
+	StickyPrioritySessionCookieName     string `json:"sticky_priority_session_cookie_name" toml:"sticky_priority_session_cookie_name"`
+	StickyPrioritySessionCookieDomain   string `json:"sticky_priority_session_cookie_domain" toml:"sticky_priority_session_cookie_domain"`
+	StickyPrioritySessionCookieSecure   bool   `json:"sticky_priority_session_cookie_secure" toml:"sticky_priority_session_cookie_secure"`
+	StickyPrioritySessionCookieHttpOnly bool   `json:"sticky_priority_session_cookie_http_only" toml:"sticky_priority_session_cookie_http_only"`
+
+	/* 0 means unbounded */
+	StickyPrioritySessionMaxSessions     int  `json:"sticky_priority_session_max_sessions" toml:"sticky_priority_session_max_sessions"`
+	StickyPrioritySessionLeakDiagnostics bool `json:"sticky_priority_session_leak_diagnostics" toml:"sticky_priority_session_leak_diagnostics"`
+
+	StickyPrioritySessionElectRetryBase        string `json:"sticky_priority_session_elect_retry_base" toml:"sticky_priority_session_elect_retry_base"`
+	StickyPrioritySessionElectRetryCap         string `json:"sticky_priority_session_elect_retry_cap" toml:"sticky_priority_session_elect_retry_cap"`
+	StickyPrioritySessionElectRetryMaxAttempts int    `json:"sticky_priority_session_elect_retry_max_attempts" toml:"sticky_priority_session_elect_retry_max_attempts"`
+	StickyPrioritySessionElectRetryDeadline    string `json:"sticky_priority_session_elect_retry_deadline" toml:"sticky_priority_session_elect_retry_deadline"`
+	StickyPrioritySessionLastResortBackend     string `json:"sticky_priority_session_last_resort_backend" toml:"sticky_priority_session_last_resort_backend"`
+
+	/* session persistence: "" (default, in-memory only), "file" or "redis" */
+	StickyPrioritySessionStoreType          string `json:"sticky_priority_session_store_type" toml:"sticky_priority_session_store_type"`
+	StickyPrioritySessionStorePath          string `json:"sticky_priority_session_store_path" toml:"sticky_priority_session_store_path"`
+	StickyPrioritySessionStoreRedisAddr     string `json:"sticky_priority_session_store_redis_addr" toml:"sticky_priority_session_store_redis_addr"`
+	StickyPrioritySessionStoreRedisPassword string `json:"sticky_priority_session_store_redis_password" toml:"sticky_priority_session_store_redis_password"`
+	StickyPrioritySessionStoreRedisDb       int    `json:"sticky_priority_session_store_redis_db" toml:"sticky_priority_session_store_redis_db"`
+	StickyPrioritySessionStoreRedisPrefix   string `json:"sticky_priority_session_store_redis_prefix" toml:"sticky_priority_session_store_redis_prefix"`
+	StickyPrioritySessionStoreRedisTtl      string `json:"sticky_priority_session_store_redis_ttl" toml:"sticky_priority_session_store_redis_ttl"`
+}
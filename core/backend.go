@@ -0,0 +1,49 @@
+/**
+ * backend.go - a single real server in a pool that a Balancer can elect.
+ */
+
+package core
+
+import "net"
+
+/**
+ * Target is the dialable address of a Backend.
+ */
+type Target struct {
+	Host string
+	Port string
+}
+
+/**
+ * Backend is a real server behind gobetween, along with the bits of
+ * state a Balancer needs to elect (or stop electing) it.
+ */
+type Backend struct {
+	Target
+
+	Priority int
+	Weight   int
+
+	/* set by the health check / admin API once a backend is being drained
+	   ahead of removal - sticky balancers must stop handing it *new*
+	   sessions but may keep it for clients already pinned to it */
+	DrainSessions bool
+}
+
+/**
+ * NewBackend builds a Backend from a "host:port" address.
+ */
+func NewBackend(address string) *Backend {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	return &Backend{Target: Target{Host: host, Port: port}}
+}
+
+/**
+ * Address returns the backend's dialable "host:port" address.
+ */
+func (b *Backend) Address() string {
+	return net.JoinHostPort(b.Host, b.Port)
+}
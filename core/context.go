@@ -0,0 +1,45 @@
+/**
+ * context.go - per-connection context threaded through a Balancer's Elect
+ * call.
+ */
+
+package core
+
+import "net"
+
+/**
+ * Context carries the per-connection information a Balancer needs to
+ * elect a backend. Protocol-specific listeners (TCP, UDP, HTTP) each
+ * provide their own implementation; balancers that need more than Ip()
+ * (eg. cookies) type-assert for the extra capability instead of requiring
+ * it here, since most protocols can't provide it.
+ */
+type Context interface {
+	Ip() net.IP
+}
+
+/**
+ * ipContext is the minimal Context implementation, used directly by TCP/
+ * UDP listeners and as the base embedded by richer contexts such as
+ * HttpContext.
+ */
+type ipContext struct {
+	ip net.IP
+}
+
+func (c *ipContext) Ip() net.IP {
+	return c.ip
+}
+
+/**
+ * NewContext builds the minimal ip-only Context. ip may be a bare address
+ * or a "host:port" pair, same as what net.Conn.RemoteAddr().String()
+ * returns.
+ */
+func NewContext(ip string) Context {
+	host, _, err := net.SplitHostPort(ip)
+	if err != nil {
+		host = ip
+	}
+	return &ipContext{ip: net.ParseIP(host)}
+}
@@ -0,0 +1,72 @@
+/**
+ * http_context.go - Context implementation for the L7 HTTP/HTTPS proxy,
+ * the only listener that can carry cookies or be cancelled mid-request.
+ */
+
+package core
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+/**
+ * HttpContext wraps the request/response pair of a proxied HTTP
+ * connection. Balancers that want cookie based affinity or cancellation
+ * (see balance.cookieContext) type-assert for it rather than requiring it
+ * on Context, since TCP/UDP contexts can't implement either.
+ */
+type HttpContext struct {
+	ip  net.IP
+	req *http.Request
+	rw  http.ResponseWriter
+}
+
+/**
+ * NewHttpContext builds a Context for a single proxied HTTP request/
+ * response pair.
+ */
+func NewHttpContext(ip net.IP, req *http.Request, rw http.ResponseWriter) *HttpContext {
+	return &HttpContext{ip: ip, req: req, rw: rw}
+}
+
+func (c *HttpContext) Ip() net.IP {
+	return c.ip
+}
+
+/**
+ * Cookie returns the named cookie's value off the incoming request, if
+ * present. Satisfies balance.cookieContext.
+ */
+func (c *HttpContext) Cookie(name string) (string, bool) {
+	cookie, err := c.req.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+/**
+ * SetCookie issues a cookie on the response. Satisfies
+ * balance.cookieContext.
+ */
+func (c *HttpContext) SetCookie(name, value, domain string, ttl time.Duration, secure, httpOnly bool) {
+	http.SetCookie(c.rw, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   domain,
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   secure,
+		HttpOnly: httpOnly,
+	})
+}
+
+/**
+ * Done reports when the underlying request's connection has gone away, so
+ * a balancer retrying/backing off on this context's behalf can give up
+ * early instead of outliving a client that already disconnected.
+ */
+func (c *HttpContext) Done() <-chan struct{} {
+	return c.req.Context().Done()
+}